@@ -0,0 +1,136 @@
+// +build go1.7
+
+package ginhttp
+
+import (
+	"net/http"
+	"net/http/httptrace"
+	"net/url"
+
+	opentracing "github.com/opentracing/opentracing-go"
+	"github.com/opentracing/opentracing-go/ext"
+	"github.com/opentracing/opentracing-go/log"
+)
+
+type clientOptions struct {
+	opNameFunc    func(r *http.Request) string
+	componentName string
+	spanObserver  func(span opentracing.Span, r *http.Request)
+	urlTagFunc    func(u *url.URL) string
+	clientTrace   bool
+}
+
+// ClientOption controls the behavior of NewTransport.
+type ClientOption func(*clientOptions)
+
+// ClientOperationName returns a ClientOption that uses given function f
+// to generate the operation name for each client-side span.
+func ClientOperationName(f func(r *http.Request) string) ClientOption {
+	return func(options *clientOptions) {
+		options.opNameFunc = f
+	}
+}
+
+// ClientComponentName returns a ClientOption that sets the component
+// name for the client-side span.
+func ClientComponentName(componentName string) ClientOption {
+	return func(options *clientOptions) {
+		options.componentName = componentName
+	}
+}
+
+// ClientSpanObserver returns a ClientOption that observes the span
+// for the client-side span.
+func ClientSpanObserver(f func(span opentracing.Span, r *http.Request)) ClientOption {
+	return func(options *clientOptions) {
+		options.spanObserver = f
+	}
+}
+
+// ClientTrace returns a ClientOption that turns on or off extra
+// instrumentation via httptrace.WithClientTrace, reported as
+// connection/DNS/TLS timing sub-spans of the request span.
+func ClientTrace(enabled bool) ClientOption {
+	return func(options *clientOptions) {
+		options.clientTrace = enabled
+	}
+}
+
+// Transport wraps a base http.RoundTripper, starting a child span from
+// the opentracing.Span found in the outgoing request's context (the
+// one ginhttp.Middleware injects into c.Request.Context() on the
+// inbound side) for every outbound call.
+type Transport struct {
+	base http.RoundTripper
+	tr   opentracing.Tracer
+	opts clientOptions
+}
+
+// NewTransport returns an http.RoundTripper that traces outbound
+// requests made through it. base is the underlying RoundTripper to use;
+// a nil base defaults to http.DefaultTransport. Pairing NewTransport
+// with Middleware lets a single import wire up both inbound and
+// outbound tracing on a Gin service.
+func NewTransport(base http.RoundTripper, tr opentracing.Tracer, opts ...ClientOption) http.RoundTripper {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	options := clientOptions{
+		opNameFunc: func(r *http.Request) string {
+			return "HTTP Client " + r.Method
+		},
+		spanObserver: func(_ opentracing.Span, _ *http.Request) {},
+		urlTagFunc: func(u *url.URL) string {
+			return u.String()
+		},
+	}
+	for _, opt := range opts {
+		opt(&options)
+	}
+	return &Transport{base: base, tr: tr, opts: options}
+}
+
+// RoundTrip implements the http.RoundTripper interface.
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	var parentCtx opentracing.SpanContext
+	if parent := opentracing.SpanFromContext(req.Context()); parent != nil {
+		parentCtx = parent.Context()
+	}
+
+	sp := t.tr.StartSpan(t.opts.opNameFunc(req), opentracing.ChildOf(parentCtx), ext.SpanKindRPCClient)
+	defer sp.Finish()
+
+	componentName := t.opts.componentName
+	if componentName == "" {
+		componentName = defaultComponentName
+	}
+	ext.Component.Set(sp, componentName)
+	ext.HTTPMethod.Set(sp, req.Method)
+	ext.HTTPUrl.Set(sp, t.opts.urlTagFunc(req.URL))
+	t.opts.spanObserver(sp, req)
+
+	ctx := opentracing.ContextWithSpan(req.Context(), sp)
+	if t.opts.clientTrace {
+		ctx = httptrace.WithClientTrace(ctx, newClientTrace(t.tr, sp))
+	}
+	req = req.WithContext(ctx)
+
+	carrier := opentracing.HTTPHeadersCarrier(req.Header)
+	if err := t.tr.Inject(sp.Context(), opentracing.HTTPHeaders, carrier); err != nil {
+		sp.LogFields(log.String("event", "error"), log.Error(err))
+	}
+
+	resp, err := t.base.RoundTrip(req)
+	if err != nil {
+		ext.Error.Set(sp, true)
+		sp.LogFields(log.String("event", "error"), log.Error(err))
+		return resp, err
+	}
+
+	ext.HTTPStatusCode.Set(sp, uint16(resp.StatusCode))
+	if resp.StatusCode >= http.StatusInternalServerError {
+		ext.Error.Set(sp, true)
+	}
+
+	return resp, nil
+}
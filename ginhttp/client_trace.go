@@ -0,0 +1,105 @@
+// +build go1.7
+
+package ginhttp
+
+import (
+	"crypto/tls"
+	"net/http/httptrace"
+
+	opentracing "github.com/opentracing/opentracing-go"
+	"github.com/opentracing/opentracing-go/ext"
+	"github.com/opentracing/opentracing-go/log"
+)
+
+// clientTracer reports connection/DNS/TLS timing as child spans of the
+// request span, one per httptrace event pair.
+type clientTracer struct {
+	tr   opentracing.Tracer
+	root opentracing.Span
+
+	dns     opentracing.Span
+	connect opentracing.Span
+	tls     opentracing.Span
+}
+
+func newClientTrace(tr opentracing.Tracer, root opentracing.Span) *httptrace.ClientTrace {
+	t := &clientTracer{tr: tr, root: root}
+	return &httptrace.ClientTrace{
+		DNSStart:             t.dnsStart,
+		DNSDone:              t.dnsDone,
+		ConnectStart:         t.connectStart,
+		ConnectDone:          t.connectDone,
+		TLSHandshakeStart:    t.tlsHandshakeStart,
+		TLSHandshakeDone:     t.tlsHandshakeDone,
+		GotConn:              t.gotConn,
+		WroteRequest:         t.wroteRequest,
+		GotFirstResponseByte: t.gotFirstResponseByte,
+	}
+}
+
+func (t *clientTracer) startChild(name string) opentracing.Span {
+	return t.tr.StartSpan(name, opentracing.ChildOf(t.root.Context()))
+}
+
+func (t *clientTracer) dnsStart(httptrace.DNSStartInfo) {
+	t.dns = t.startChild("dns")
+}
+
+func (t *clientTracer) dnsDone(info httptrace.DNSDoneInfo) {
+	if t.dns == nil {
+		return
+	}
+	if info.Err != nil {
+		ext.Error.Set(t.dns, true)
+		t.dns.LogFields(log.Error(info.Err))
+	}
+	t.dns.Finish()
+}
+
+func (t *clientTracer) connectStart(network, addr string) {
+	t.connect = t.startChild("connect")
+	t.connect.SetTag("net.transport", network)
+	t.connect.SetTag("peer.address", addr)
+}
+
+func (t *clientTracer) connectDone(network, addr string, err error) {
+	if t.connect == nil {
+		return
+	}
+	if err != nil {
+		ext.Error.Set(t.connect, true)
+		t.connect.LogFields(log.Error(err))
+	}
+	t.connect.Finish()
+}
+
+func (t *clientTracer) tlsHandshakeStart() {
+	t.tls = t.startChild("tls")
+}
+
+func (t *clientTracer) tlsHandshakeDone(_ tls.ConnectionState, err error) {
+	if t.tls == nil {
+		return
+	}
+	if err != nil {
+		ext.Error.Set(t.tls, true)
+		t.tls.LogFields(log.Error(err))
+	}
+	t.tls.Finish()
+}
+
+func (t *clientTracer) gotConn(info httptrace.GotConnInfo) {
+	t.root.SetTag("net/http.reused", info.Reused)
+	t.root.SetTag("net/http.was_idle", info.WasIdle)
+}
+
+func (t *clientTracer) wroteRequest(info httptrace.WroteRequestInfo) {
+	if info.Err != nil {
+		ext.Error.Set(t.root, true)
+		t.root.LogFields(log.String("event", "error"), log.Error(info.Err))
+	}
+}
+
+func (t *clientTracer) gotFirstResponseByte() {
+	t.root.LogFields(log.String("event", "GotFirstResponseByte"))
+}
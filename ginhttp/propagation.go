@@ -0,0 +1,205 @@
+// +build go1.7
+
+package ginhttp
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	opentracing "github.com/opentracing/opentracing-go"
+	jaeger "github.com/uber/jaeger-client-go"
+)
+
+// PropagationFormat identifies a wire format MWPropagators can extract
+// an incoming span context from.
+type PropagationFormat int
+
+const (
+	// FormatB3 extracts Zipkin B3 headers: the single "b3" header, or
+	// the multi-header X-B3-TraceId/X-B3-SpanId/X-B3-ParentSpanId/
+	// X-B3-Sampled/X-B3-Flags family.
+	FormatB3 PropagationFormat = iota
+	// FormatTraceContext extracts the W3C traceparent/tracestate
+	// headers.
+	FormatTraceContext
+	// FormatJaeger extracts the Jaeger uber-trace-id header.
+	FormatJaeger
+)
+
+// uberTraceIDHeader is the header the Jaeger client's HTTPHeaders
+// propagator reads (github.com/uber/jaeger-client-go).
+const uberTraceIDHeader = "uber-trace-id"
+
+// baggageHeaderPrefix is the header prefix jaeger-client-go reads
+// per-request baggage from.
+const baggageHeaderPrefix = "uberctx-"
+
+// tracestateBaggageKey stashes the W3C tracestate header as a baggage
+// item, since jaeger.SpanContext has no dedicated slot for it; without
+// this it would otherwise be silently dropped on the FormatTraceContext
+// path.
+const tracestateBaggageKey = "tracestate"
+
+// extractSpanContext walks formats in order, returning the
+// jaeger.SpanContext built from the first one whose headers parse.
+//
+// The result is jaeger-client-go's own concrete SpanContext type, not a
+// generic placeholder. That's deliberate: jaeger.Tracer.StartSpan only
+// honors a ChildOf/FollowsFrom reference whose ReferencedContext is
+// exactly jaeger.SpanContext (see Tracer.startSpanWithOptions in
+// jaeger-client-go), so handing it anything else silently drops the
+// reference and starts a fresh root span instead. Every other
+// opentracing.Tracer implementation we've checked imposes the identical
+// restriction for its own concrete type -- including the OTel bridge
+// tracer in ginotel, whose StartSpan ignores any ReferencedContext that
+// isn't its own *bridgeSpanContext. There is no SpanContext value that
+// every opentracing.Tracer will accept as a parent; MWPropagators is
+// therefore only useful paired with a jaeger-client-go Tracer (the
+// tracer this repo ships with), to accept B3 or W3C TraceContext headers
+// from a mesh/proxy that doesn't speak Jaeger's native uber-trace-id
+// format. Pass it a different tracer and extraction will still parse
+// correctly, but StartSpan will quietly treat the result as a root span.
+func extractSpanContext(h http.Header, formats []PropagationFormat) (opentracing.SpanContext, bool) {
+	for _, format := range formats {
+		sc, ok := jaegerSpanContextForFormat(h, format)
+		if !ok {
+			continue
+		}
+		return sc, true
+	}
+	return nil, false
+}
+
+// jaegerSpanContextForFormat parses the headers for format and, if
+// well-formed, returns the equivalent jaeger.SpanContext with baggage
+// (uberctx-* headers, plus tracestate for FormatTraceContext) attached.
+func jaegerSpanContextForFormat(h http.Header, format PropagationFormat) (jaeger.SpanContext, bool) {
+	var normalized string
+	switch format {
+	case FormatB3:
+		v, ok := normalizeB3(h)
+		if !ok {
+			return jaeger.SpanContext{}, false
+		}
+		normalized = v
+	case FormatTraceContext:
+		v, ok := normalizeTraceContext(h)
+		if !ok {
+			return jaeger.SpanContext{}, false
+		}
+		normalized = v
+	case FormatJaeger:
+		normalized = h.Get(uberTraceIDHeader)
+		if normalized == "" {
+			return jaeger.SpanContext{}, false
+		}
+	default:
+		return jaeger.SpanContext{}, false
+	}
+
+	sc, err := jaeger.ContextFromString(normalized)
+	if err != nil {
+		return jaeger.SpanContext{}, false
+	}
+	for k, v := range baggageHeaders(h) {
+		sc = sc.WithBaggageItem(k, v)
+	}
+	if format == FormatTraceContext {
+		if ts := h.Get("tracestate"); ts != "" {
+			sc = sc.WithBaggageItem(tracestateBaggageKey, ts)
+		}
+	}
+	return sc, true
+}
+
+// baggageHeaders collects uberctx-* headers into a baggage map.
+func baggageHeaders(h http.Header) map[string]string {
+	baggage := make(map[string]string)
+	for key, values := range h {
+		if strings.HasPrefix(strings.ToLower(key), baggageHeaderPrefix) {
+			for _, v := range values {
+				baggage[strings.TrimPrefix(strings.ToLower(key), baggageHeaderPrefix)] = v
+			}
+		}
+	}
+	return baggage
+}
+
+// normalizeB3 parses Zipkin B3 propagation headers -- preferring the
+// single "b3" header over the multi-header form -- into jaeger's
+// "{traceID}:{spanID}:{parentID}:{flags}" wire format.
+func normalizeB3(h http.Header) (string, bool) {
+	if b3 := h.Get("b3"); b3 != "" {
+		return parseB3Single(b3)
+	}
+
+	traceID := h.Get("X-B3-TraceId")
+	spanID := h.Get("X-B3-SpanId")
+	if traceID == "" || spanID == "" {
+		return "", false
+	}
+
+	parentSpanID := h.Get("X-B3-ParentSpanId")
+	if parentSpanID == "" {
+		parentSpanID = "0"
+	}
+	var flags byte
+	if h.Get("X-B3-Sampled") == "1" {
+		flags |= 1
+	}
+	if h.Get("X-B3-Flags") == "1" {
+		// Debug implies sampled: a debug-flagged request must not be
+		// silently dropped by a downstream sampler that only checks
+		// the sampled bit.
+		flags |= 1 | 2
+	}
+	return traceID + ":" + spanID + ":" + parentSpanID + ":" + strconv.Itoa(int(flags)), true
+}
+
+// parseB3Single parses the single-header B3 format:
+// {TraceId}-{SpanId}-{SamplingState}-{ParentSpanId}, where the last two
+// fields are optional. SamplingState is "0"/"1"/"d" (debug).
+func parseB3Single(v string) (string, bool) {
+	parts := strings.Split(v, "-")
+	if len(parts) < 2 || parts[0] == "" || parts[1] == "" {
+		return "", false
+	}
+	traceID, spanID := parts[0], parts[1]
+	parentSpanID := "0"
+	var flags byte
+	if len(parts) >= 3 {
+		switch parts[2] {
+		case "1":
+			flags |= 1
+		case "d":
+			flags |= 1 | 2
+		}
+	}
+	if len(parts) >= 4 && parts[3] != "" {
+		parentSpanID = parts[3]
+	}
+	return traceID + ":" + spanID + ":" + parentSpanID + ":" + strconv.Itoa(int(flags)), true
+}
+
+// normalizeTraceContext parses the W3C traceparent header --
+// {version}-{trace-id:32hex}-{parent-id:16hex}-{trace-flags:2hex} --
+// into jaeger's "{traceID}:{spanID}:{parentID}:{flags}" wire format.
+// tracestate, if present, is attached as baggage by the caller since
+// jaeger's wire format has no field for it.
+func normalizeTraceContext(h http.Header) (string, bool) {
+	tp := h.Get("traceparent")
+	parts := strings.Split(tp, "-")
+	if len(parts) != 4 || len(parts[1]) != 32 || len(parts[2]) != 16 {
+		return "", false
+	}
+	traceFlags, err := strconv.ParseUint(parts[3], 16, 8)
+	if err != nil {
+		return "", false
+	}
+	var flags byte
+	if traceFlags&0x01 != 0 {
+		flags |= 1
+	}
+	return parts[1] + ":" + parts[2] + ":0:" + strconv.Itoa(int(flags)), true
+}
@@ -0,0 +1,184 @@
+// +build go1.7
+
+package ginhttp
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/opentracing/opentracing-go/ext"
+	jaeger "github.com/uber/jaeger-client-go"
+)
+
+func TestExtractSpanContext(t *testing.T) {
+	tests := []struct {
+		name        string
+		headers     map[string]string
+		formats     []PropagationFormat
+		wantFound   bool
+		wantTraceID string
+		wantSpanID  string
+		wantSampled bool
+		wantDebug   bool
+		wantBaggage map[string]string
+	}{
+		{
+			name:        "b3 single header",
+			headers:     map[string]string{"b3": "80f198ee56343ba864fe8b2a57d3eff7-e457b5a2e4d86bd1-1"},
+			formats:     []PropagationFormat{FormatB3},
+			wantFound:   true,
+			wantTraceID: "80f198ee56343ba864fe8b2a57d3eff7",
+			wantSpanID:  "e457b5a2e4d86bd1",
+			wantSampled: true,
+		},
+		{
+			name:        "b3 single header debug flag implies sampled",
+			headers:     map[string]string{"b3": "80f198ee56343ba864fe8b2a57d3eff7-e457b5a2e4d86bd1-d"},
+			formats:     []PropagationFormat{FormatB3},
+			wantFound:   true,
+			wantTraceID: "80f198ee56343ba864fe8b2a57d3eff7",
+			wantSpanID:  "e457b5a2e4d86bd1",
+			wantSampled: true,
+			wantDebug:   true,
+		},
+		{
+			name: "b3 multi-header debug flag also implies sampled",
+			headers: map[string]string{
+				"X-B3-TraceId": "80f198ee56343ba864fe8b2a57d3eff7",
+				"X-B3-SpanId":  "e457b5a2e4d86bd1",
+				"X-B3-Flags":   "1",
+			},
+			formats:     []PropagationFormat{FormatB3},
+			wantFound:   true,
+			wantTraceID: "80f198ee56343ba864fe8b2a57d3eff7",
+			wantSpanID:  "e457b5a2e4d86bd1",
+			wantSampled: true,
+			wantDebug:   true,
+		},
+		{
+			name:        "w3c traceparent",
+			headers:     map[string]string{"traceparent": "00-80f198ee56343ba864fe8b2a57d3eff7-e457b5a2e4d86bd1-01"},
+			formats:     []PropagationFormat{FormatTraceContext},
+			wantFound:   true,
+			wantTraceID: "80f198ee56343ba864fe8b2a57d3eff7",
+			wantSpanID:  "e457b5a2e4d86bd1",
+			wantSampled: true,
+		},
+		{
+			name:        "w3c tracestate preserved as baggage since jaeger has no slot for it",
+			headers:     map[string]string{"traceparent": "00-80f198ee56343ba864fe8b2a57d3eff7-e457b5a2e4d86bd1-01", "tracestate": "congo=t61rcWkgMzE"},
+			formats:     []PropagationFormat{FormatTraceContext},
+			wantFound:   true,
+			wantTraceID: "80f198ee56343ba864fe8b2a57d3eff7",
+			wantSpanID:  "e457b5a2e4d86bd1",
+			wantSampled: true,
+			wantBaggage: map[string]string{"tracestate": "congo=t61rcWkgMzE"},
+		},
+		{
+			name:        "jaeger uber-trace-id passed straight through",
+			headers:     map[string]string{"uber-trace-id": "80f198ee56343ba864fe8b2a57d3eff7:e457b5a2e4d86bd1:0:1"},
+			formats:     []PropagationFormat{FormatJaeger},
+			wantFound:   true,
+			wantTraceID: "80f198ee56343ba864fe8b2a57d3eff7",
+			wantSpanID:  "e457b5a2e4d86bd1",
+			wantSampled: true,
+		},
+		{
+			name:        "uberctx baggage forwarded regardless of format",
+			headers:     map[string]string{"b3": "80f198ee56343ba864fe8b2a57d3eff7-e457b5a2e4d86bd1-1", "uberctx-user": "42"},
+			formats:     []PropagationFormat{FormatB3},
+			wantFound:   true,
+			wantTraceID: "80f198ee56343ba864fe8b2a57d3eff7",
+			wantSpanID:  "e457b5a2e4d86bd1",
+			wantSampled: true,
+			wantBaggage: map[string]string{"user": "42"},
+		},
+		{
+			name:      "falls through to the next format when the first doesn't match",
+			headers:   map[string]string{"traceparent": "00-80f198ee56343ba864fe8b2a57d3eff7-e457b5a2e4d86bd1-01"},
+			formats:   []PropagationFormat{FormatB3, FormatTraceContext},
+			wantFound: true, wantTraceID: "80f198ee56343ba864fe8b2a57d3eff7", wantSpanID: "e457b5a2e4d86bd1", wantSampled: true,
+		},
+		{
+			name:      "malformed traceparent is skipped, not forwarded",
+			headers:   map[string]string{"traceparent": "not-a-traceparent"},
+			formats:   []PropagationFormat{FormatTraceContext},
+			wantFound: false,
+		},
+		{
+			name:      "no matching headers at all",
+			headers:   map[string]string{},
+			formats:   []PropagationFormat{FormatB3, FormatTraceContext, FormatJaeger},
+			wantFound: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := make(http.Header)
+			for k, v := range tt.headers {
+				h.Set(k, v)
+			}
+
+			sc, ok := extractSpanContext(h, tt.formats)
+			if ok != tt.wantFound {
+				t.Fatalf("extractSpanContext() ok = %v, want %v", ok, tt.wantFound)
+			}
+			if !tt.wantFound {
+				return
+			}
+			jsc, ok := sc.(jaeger.SpanContext)
+			if !ok {
+				t.Fatalf("extractSpanContext() returned %T, want jaeger.SpanContext", sc)
+			}
+			if got := jsc.TraceID().String(); got != tt.wantTraceID {
+				t.Errorf("TraceID = %q, want %q", got, tt.wantTraceID)
+			}
+			if got := jsc.SpanID().String(); got != tt.wantSpanID {
+				t.Errorf("SpanID = %q, want %q", got, tt.wantSpanID)
+			}
+			if jsc.IsSampled() != tt.wantSampled {
+				t.Errorf("IsSampled() = %v, want %v", jsc.IsSampled(), tt.wantSampled)
+			}
+			if jsc.IsDebug() != tt.wantDebug {
+				t.Errorf("IsDebug() = %v, want %v", jsc.IsDebug(), tt.wantDebug)
+			}
+			baggage := make(map[string]string)
+			jsc.ForeachBaggageItem(func(k, v string) bool {
+				baggage[k] = v
+				return true
+			})
+			for k, want := range tt.wantBaggage {
+				if got := baggage[k]; got != want {
+					t.Errorf("baggage[%q] = %q, want %q", k, got, want)
+				}
+			}
+		})
+	}
+}
+
+// TestExtractSpanContextUsableAsJaegerParent proves the SpanContext
+// extractSpanContext returns isn't just structurally similar to
+// jaeger's own -- it's accepted as a real parent reference by an actual
+// jaeger.Tracer, which is the entire point of MWPropagators.
+func TestExtractSpanContextUsableAsJaegerParent(t *testing.T) {
+	tracer, closer := jaeger.NewTracer("test-service", jaeger.NewConstSampler(true), jaeger.NewInMemoryReporter())
+	defer closer.Close()
+
+	h := make(http.Header)
+	h.Set("b3", "80f198ee56343ba864fe8b2a57d3eff7-e457b5a2e4d86bd1-1")
+
+	sc, ok := extractSpanContext(h, []PropagationFormat{FormatB3})
+	if !ok {
+		t.Fatalf("extractSpanContext() = false, want true")
+	}
+
+	span := tracer.StartSpan("handle-request", ext.RPCServerOption(sc))
+	jspan, ok := span.Context().(jaeger.SpanContext)
+	if !ok {
+		t.Fatalf("span.Context() = %T, want jaeger.SpanContext", span.Context())
+	}
+	if got, want := jspan.TraceID().String(), "80f198ee56343ba864fe8b2a57d3eff7"; got != want {
+		t.Errorf("child span TraceID = %q, want %q (parent reference was not honored)", got, want)
+	}
+}
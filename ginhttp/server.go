@@ -10,49 +10,103 @@ package ginhttp
 
 import (
 	"bytes"
+	"fmt"
 	"net/http"
 	"net/url"
 
 	"github.com/gin-gonic/gin"
 	opentracing "github.com/opentracing/opentracing-go"
 	"github.com/opentracing/opentracing-go/ext"
+	"github.com/opentracing/opentracing-go/log"
+	"github.com/pkg/errors"
 )
 
 const defaultComponentName = "net/http"
 
+const defaultMaxResponseBodyBytes = 4096
+
+// stackTracer is implemented by error types (e.g. github.com/pkg/errors)
+// that can report the stack at the point they were created. The return
+// type must match errors.StackTrace exactly -- Go interface satisfaction
+// is by signature, not structure, so a lookalike declared locally would
+// never match a real *errors.fundamental.
+type stackTracer interface {
+	StackTrace() errors.StackTrace
+}
+
+// LoggingWriter wraps a gin.ResponseWriter, optionally buffering the
+// response body (capped at maxBytes) so Middleware can attach it to the
+// span on error. When capture is false it is a pass-through and pays no
+// copy cost.
 type LoggingWriter struct {
 	gin.ResponseWriter
-	Buffer *bytes.Buffer
+	Buffer    *bytes.Buffer
+	maxBytes  int
+	truncated bool
 }
 
-func NewLoggingWriter(responseWriter gin.ResponseWriter) *LoggingWriter {
-	return &LoggingWriter{
+// NewLoggingWriter wraps responseWriter. When capture is true, up to
+// maxBytes of the response body are buffered for later inspection;
+// anything beyond that is discarded and Truncated reports true.
+func NewLoggingWriter(responseWriter gin.ResponseWriter, capture bool, maxBytes int) *LoggingWriter {
+	w := &LoggingWriter{
 		ResponseWriter: responseWriter,
-		Buffer:         new(bytes.Buffer),
+		maxBytes:       maxBytes,
 	}
+	if capture {
+		w.Buffer = new(bytes.Buffer)
+	}
+	return w
+}
+
+// Truncated reports whether the buffered body was capped by maxBytes.
+func (l *LoggingWriter) Truncated() bool {
+	return l.truncated
 }
 
-func (l LoggingWriter) Write(data []byte) (int, error) {
-	l.Buffer.Write(data)
-	n, err := l.ResponseWriter.Write(data)
-	return n, err
+func (l *LoggingWriter) Write(data []byte) (int, error) {
+	if l.Buffer != nil {
+		if room := l.maxBytes - l.Buffer.Len(); room > 0 {
+			if room < len(data) {
+				l.Buffer.Write(data[:room])
+				l.truncated = true
+			} else {
+				l.Buffer.Write(data)
+			}
+		} else {
+			l.truncated = true
+		}
+	}
+	return l.ResponseWriter.Write(data)
 }
 
 type mwOptions struct {
-	opNameFunc    func(r *http.Request) string
-	spanObserver  func(span opentracing.Span, r *http.Request)
-	urlTagFunc    func(u *url.URL) string
-	componentName string
+	opNameFunc           func(r *http.Request) string
+	opNameFuncSet        bool
+	spanObserver         func(span opentracing.Span, r *http.Request)
+	urlTagFunc           func(u *url.URL) string
+	componentName        string
+	propagators          []PropagationFormat
+	errorStatusFunc      func(status int) bool
+	captureResponseBody  bool
+	maxResponseBodyBytes int
+	errorsFromContext    bool
+	spanFilter           func(r *http.Request, route string) bool
+	samplingPriority     func(c *gin.Context) *uint16
 }
 
 // MWOption controls the behavior of the Middleware.
 type MWOption func(*mwOptions)
 
 // OperationNameFunc returns a MWOption that uses given function f
-// to generate operation name for each server-side span.
+// to generate operation name for each server-side span. Setting this
+// opts out of the default route-template-based naming described on
+// Middleware, since f is assumed to already produce the name the
+// caller wants.
 func OperationNameFunc(f func(r *http.Request) string) MWOption {
 	return func(options *mwOptions) {
 		options.opNameFunc = f
+		options.opNameFuncSet = true
 	}
 }
 
@@ -81,8 +135,96 @@ func MWURLTagFunc(f func(u *url.URL) string) MWOption {
 	}
 }
 
+// MWPropagators returns a MWOption that extracts the incoming span
+// context from formats, tried in order, instead of relying solely on
+// the tracer's own opentracing.HTTPHeaders propagator. This lets a
+// jaeger-client-go Tracer (the tracer this repo is built around) accept
+// B3 or W3C TraceContext headers from a mesh/proxy that doesn't speak
+// Jaeger's native uber-trace-id format. It's only useful paired with a
+// jaeger-client-go Tracer: see the doc comment on extractSpanContext in
+// propagation.go for why other opentracing.Tracer implementations won't
+// honor the result as a parent span.
+func MWPropagators(formats ...PropagationFormat) MWOption {
+	return func(options *mwOptions) {
+		options.propagators = formats
+	}
+}
+
+// MWErrorStatusFunc returns a MWOption that uses given function f to
+// decide whether a response status code should mark the span as an
+// error. The default classifies only 5xx as errors.
+func MWErrorStatusFunc(f func(status int) bool) MWOption {
+	return func(options *mwOptions) {
+		options.errorStatusFunc = f
+	}
+}
+
+// MWCaptureResponseBody returns a MWOption that enables buffering the
+// response body so it can be attached to the span's message tag when
+// the response is classified as an error. Disabled by default, since
+// buffering costs a copy on every response and can leak PII into spans.
+func MWCaptureResponseBody(capture bool) MWOption {
+	return func(options *mwOptions) {
+		options.captureResponseBody = capture
+	}
+}
+
+// MWMaxResponseBodyBytes returns a MWOption that caps how much of the
+// response body is buffered when MWCaptureResponseBody is enabled.
+// Bytes beyond the cap are discarded; when the truncated body is
+// attached to an error span, the span is also tagged
+// message.truncated=true.
+func MWMaxResponseBodyBytes(n int) MWOption {
+	return func(options *mwOptions) {
+		options.maxResponseBodyBytes = n
+	}
+}
+
+// MWErrorsFromContext returns a MWOption that, when enabled, emits
+// each error in gin's per-request c.Errors slice as a span log using
+// the OpenTracing error log fields (event, error.kind, message, and
+// stack when available).
+func MWErrorsFromContext(enabled bool) MWOption {
+	return func(options *mwOptions) {
+		options.errorsFromContext = enabled
+	}
+}
+
+// MWSpanFilter returns a MWOption that uses given function f to decide
+// whether Middleware creates a span for the request at all. Returning
+// false skips span creation (and context propagation) entirely, which
+// is cheaper than creating and dropping a span for health checks,
+// metrics scrapes, and websocket upgrades. route is the matched route
+// template, i.e. the same value c.FullPath() would return; gin
+// resolves it before invoking Middleware, so it's already known on
+// entry and f is spared from re-implementing route matching.
+func MWSpanFilter(f func(r *http.Request, route string) bool) MWOption {
+	return func(options *mwOptions) {
+		options.spanFilter = f
+	}
+}
+
+// MWSamplingPriority returns a MWOption that uses given function f,
+// called after the wrapped handlers have run, to override the span's
+// sampling priority via ext.SamplingPriority -- e.g. bumping it to 1
+// for slow requests using c.Writer.Size() or elapsed time, or forcing
+// it to 0 for noisy routes. Returning nil leaves the tracer's own
+// sampling decision in place.
+func MWSamplingPriority(f func(c *gin.Context) *uint16) MWOption {
+	return func(options *mwOptions) {
+		options.samplingPriority = f
+	}
+}
+
 // Middleware is a gin native version of the equivalent middleware in:
 //   https://github.com/opentracing-contrib/go-stdlib/
+//
+// gin resolves the matched route template (c.FullPath()) before
+// invoking any middleware registered via router.Use, so it's already
+// known when Middleware runs and is used as the default operation
+// name, "Method route" (e.g. "GET /users/:id"), keeping span names
+// low-cardinality. Requests that don't match a route (404s) fall back
+// to opNameFunc's placeholder, since FullPath() is empty in that case.
 func Middleware(tr opentracing.Tracer, options ...MWOption) gin.HandlerFunc {
 	opts := mwOptions{
 		opNameFunc: func(r *http.Request) string {
@@ -92,20 +234,38 @@ func Middleware(tr opentracing.Tracer, options ...MWOption) gin.HandlerFunc {
 		urlTagFunc: func(u *url.URL) string {
 			return u.String()
 		},
+		errorStatusFunc: func(status int) bool {
+			return status >= http.StatusInternalServerError
+		},
+		maxResponseBodyBytes: defaultMaxResponseBodyBytes,
 	}
 	for _, opt := range options {
 		opt(&opts)
 	}
 
 	return func(c *gin.Context) {
-		carrier := opentracing.HTTPHeadersCarrier(c.Request.Header)
-		ctx, _ := tr.Extract(opentracing.HTTPHeaders, carrier)
+		route := c.FullPath()
+		if opts.spanFilter != nil && !opts.spanFilter(c.Request, route) {
+			c.Next()
+			return
+		}
+
+		var ctx opentracing.SpanContext
+		if len(opts.propagators) > 0 {
+			ctx, _ = extractSpanContext(c.Request.Header, opts.propagators)
+		} else {
+			carrier := opentracing.HTTPHeadersCarrier(c.Request.Header)
+			ctx, _ = tr.Extract(opentracing.HTTPHeaders, carrier)
+		}
 		op := opts.opNameFunc(c.Request)
+		if !opts.opNameFuncSet && route != "" {
+			op = c.Request.Method + " " + route
+		}
 		sp := tr.StartSpan(op, ext.RPCServerOption(ctx))
 		ext.HTTPMethod.Set(sp, c.Request.Method)
 		ext.HTTPUrl.Set(sp, opts.urlTagFunc(c.Request.URL))
 		opts.spanObserver(sp, c.Request)
-		writer := NewLoggingWriter(c.Writer)
+		writer := NewLoggingWriter(c.Writer, opts.captureResponseBody, opts.maxResponseBodyBytes)
 		c.Writer = writer
 
 		// set component name, use "net/http" if caller does not specify
@@ -119,17 +279,46 @@ func Middleware(tr opentracing.Tracer, options ...MWOption) gin.HandlerFunc {
 
 		c.Next()
 
+		if route != "" {
+			sp.SetTag("http.route", route)
+		}
+
 		// Perform appropriate logging for errors
 		statusCode := c.Writer.Status()
 
-		if statusCode != 200 && statusCode != 204 && statusCode != 302 && statusCode != 301 {
+		if opts.errorStatusFunc(statusCode) {
 			sp.SetTag("error", true)
 			sp.SetTag("event", "error")
-			sp.SetTag("message", string(writer.Buffer.Bytes()))
+			if writer.Buffer != nil {
+				sp.SetTag("message", writer.Buffer.String())
+				if writer.Truncated() {
+					sp.SetTag("message.truncated", true)
+				}
+			}
+		}
+
+		if opts.errorsFromContext {
+			for _, ginErr := range c.Errors {
+				fields := []log.Field{
+					log.String("event", "error"),
+					log.String("error.kind", fmt.Sprintf("%T", ginErr.Err)),
+					log.String("message", ginErr.Error()),
+				}
+				if st, ok := ginErr.Err.(stackTracer); ok {
+					fields = append(fields, log.String("stack", fmt.Sprintf("%+v", st.StackTrace())))
+				}
+				sp.LogFields(fields...)
+			}
 		}
 
 		ext.HTTPStatusCode.Set(sp, uint16(statusCode))
 
+		if opts.samplingPriority != nil {
+			if priority := opts.samplingPriority(c); priority != nil {
+				ext.SamplingPriority.Set(sp, *priority)
+			}
+		}
+
 		sp.Finish()
 	}
 }
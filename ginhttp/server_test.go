@@ -0,0 +1,178 @@
+// +build go1.7
+
+package ginhttp
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/opentracing/opentracing-go/mocktracer"
+	"github.com/pkg/errors"
+)
+
+func init() {
+	gin.SetMode(gin.TestMode)
+}
+
+func TestMiddlewareDefaultOperationName(t *testing.T) {
+	tests := []struct {
+		name       string
+		route      string
+		path       string
+		wantOpName string
+	}{
+		{
+			name:       "matched route uses low-cardinality Method route name",
+			route:      "/users/:id",
+			path:       "/users/42",
+			wantOpName: "GET /users/:id",
+		},
+		{
+			name:       "unmatched route (404) falls back to the RequestURI placeholder",
+			route:      "",
+			path:       "/does-not-exist",
+			wantOpName: "HTTP router GET - /does-not-exist",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tracer := mocktracer.New()
+			router := gin.New()
+			router.Use(Middleware(tracer))
+			if tt.route != "" {
+				router.GET(tt.route, func(c *gin.Context) {})
+			}
+
+			req := httptest.NewRequest(http.MethodGet, tt.path, nil)
+			rec := httptest.NewRecorder()
+			router.ServeHTTP(rec, req)
+
+			spans := tracer.FinishedSpans()
+			if len(spans) != 1 {
+				t.Fatalf("got %d finished spans, want 1", len(spans))
+			}
+			if got := spans[0].OperationName; got != tt.wantOpName {
+				t.Errorf("OperationName = %q, want %q", got, tt.wantOpName)
+			}
+		})
+	}
+}
+
+func TestMiddlewareErrorStatusDefaultBoundary(t *testing.T) {
+	tests := []struct {
+		status    int
+		wantError bool
+	}{
+		{status: http.StatusOK, wantError: false},
+		{status: 499, wantError: false},
+		{status: http.StatusInternalServerError, wantError: true},
+		{status: 503, wantError: true},
+	}
+
+	for _, tt := range tests {
+		tracer := mocktracer.New()
+		router := gin.New()
+		router.Use(Middleware(tracer))
+		router.GET("/ping", func(c *gin.Context) {
+			c.Status(tt.status)
+		})
+
+		req := httptest.NewRequest(http.MethodGet, "/ping", nil)
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+
+		span := tracer.FinishedSpans()[0]
+		_, gotError := span.Tags()["error"]
+		if gotError != tt.wantError {
+			t.Errorf("status %d: error tag set = %v, want %v", tt.status, gotError, tt.wantError)
+		}
+	}
+}
+
+func TestMiddlewareResponseBodyTruncation(t *testing.T) {
+	tracer := mocktracer.New()
+	router := gin.New()
+	router.Use(Middleware(tracer, MWCaptureResponseBody(true), MWMaxResponseBodyBytes(8)))
+	router.GET("/ping", func(c *gin.Context) {
+		c.Status(http.StatusInternalServerError)
+		c.Writer.Write([]byte("0123456789"))
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/ping", nil)
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+
+	span := tracer.FinishedSpans()[0]
+	tags := span.Tags()
+	if got, want := tags["message"], "01234567"; got != want {
+		t.Errorf("message tag = %q, want %q", got, want)
+	}
+	if got := tags["message.truncated"]; got != true {
+		t.Errorf("message.truncated tag = %v, want true", got)
+	}
+	// The client still receives the full body -- only the span's copy is capped.
+	if got, want := rec.Body.String(), "0123456789"; got != want {
+		t.Errorf("response body = %q, want %q", got, want)
+	}
+}
+
+func TestMiddlewareSpanFilterSkipsSpanEntirely(t *testing.T) {
+	tracer := mocktracer.New()
+	router := gin.New()
+	router.Use(Middleware(tracer, MWSpanFilter(func(r *http.Request, route string) bool {
+		return route != "/healthz"
+	})))
+	router.GET("/healthz", func(c *gin.Context) {})
+	router.GET("/ping", func(c *gin.Context) {})
+
+	for _, path := range []string{"/healthz", "/ping"} {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+	}
+
+	spans := tracer.FinishedSpans()
+	if len(spans) != 1 {
+		t.Fatalf("got %d finished spans, want 1 (only /ping)", len(spans))
+	}
+	if got := spans[0].OperationName; got != "GET /ping" {
+		t.Errorf("OperationName = %q, want %q", got, "GET /ping")
+	}
+}
+
+func TestMiddlewareErrorsFromContextLogsStack(t *testing.T) {
+	tracer := mocktracer.New()
+	router := gin.New()
+	router.Use(Middleware(tracer, MWErrorsFromContext(true)))
+	router.GET("/ping", func(c *gin.Context) {
+		// errors.New (github.com/pkg/errors) attaches a real stack trace,
+		// satisfying stackTracer the same way an app's wrapped errors would.
+		c.Error(errors.New("boom"))
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/ping", nil)
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+
+	span := tracer.FinishedSpans()[0]
+	logs := span.Logs()
+	if len(logs) != 1 {
+		t.Fatalf("got %d log records, want 1", len(logs))
+	}
+
+	var sawStack bool
+	for _, f := range logs[0].Fields {
+		if f.Key == "stack" {
+			sawStack = true
+			if f.ValueString == "" {
+				t.Errorf("stack field is empty")
+			}
+		}
+	}
+	if !sawStack {
+		t.Errorf("no stack field logged for a stackTracer-satisfying error")
+	}
+}
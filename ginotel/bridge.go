@@ -0,0 +1,18 @@
+// +build go1.7
+
+package ginotel
+
+import (
+	opentracing "github.com/opentracing/opentracing-go"
+	otelbridge "go.opentelemetry.io/otel/bridge/opentracing"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// NewBridgeTracer returns an opentracing.Tracer backed by the given
+// OpenTelemetry TracerProvider via the OTel/OpenTracing bridge, so that
+// code still calling ginhttp.Middleware (or any other OpenTracing API)
+// can be pointed at an OpenTelemetry backend without being rewritten.
+func NewBridgeTracer(tp trace.TracerProvider) opentracing.Tracer {
+	bridge, _ := otelbridge.NewTracerPair(tp.Tracer(tracerName))
+	return bridge
+}
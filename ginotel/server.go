@@ -0,0 +1,157 @@
+// +build go1.7
+
+// Package ginotel provides a native gin middleware that traces requests
+// using OpenTelemetry, mirroring the option surface of ginhttp.Middleware
+// for projects migrating off OpenTracing.
+package ginotel
+
+import (
+	"net/http"
+	"net/url"
+
+	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	semconv "go.opentelemetry.io/otel/semconv/v1.21.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const defaultComponentName = "net/http"
+
+const tracerName = "github.com/charm-jp/go-gin/ginotel"
+
+type mwOptions struct {
+	opNameFunc    func(r *http.Request) string
+	spanObserver  func(span trace.Span, r *http.Request)
+	urlTagFunc    func(u *url.URL) string
+	componentName string
+	spanFilter    func(r *http.Request) bool
+	propagators   propagation.TextMapPropagator
+}
+
+// Option controls the behavior of Middleware.
+type Option func(*mwOptions)
+
+// OperationNameFunc returns an Option that uses given function f to
+// generate the operation name for each server-side span.
+func OperationNameFunc(f func(r *http.Request) string) Option {
+	return func(options *mwOptions) {
+		options.opNameFunc = f
+	}
+}
+
+// ComponentName returns an Option that sets the component name for the
+// server-side span.
+func ComponentName(componentName string) Option {
+	return func(options *mwOptions) {
+		options.componentName = componentName
+	}
+}
+
+// SpanObserver returns an Option that observes the span for the
+// server-side span.
+func SpanObserver(f func(span trace.Span, r *http.Request)) Option {
+	return func(options *mwOptions) {
+		options.spanObserver = f
+	}
+}
+
+// URLTagFunc returns an Option that uses given function f to set the
+// span's http.target attribute. Can be used to change the default
+// http.target attribute, eg to redact sensitive information.
+func URLTagFunc(f func(u *url.URL) string) Option {
+	return func(options *mwOptions) {
+		options.urlTagFunc = f
+	}
+}
+
+// SpanFilter returns an Option that uses given function f to determine
+// whether a span should be created for the request. Returning false
+// skips span creation (and propagation extraction) entirely.
+func SpanFilter(f func(r *http.Request) bool) Option {
+	return func(options *mwOptions) {
+		options.spanFilter = f
+	}
+}
+
+// Propagators returns an Option that overrides the default W3C
+// TraceContext + Baggage propagators used to extract span context from
+// incoming request headers.
+func Propagators(p propagation.TextMapPropagator) Option {
+	return func(options *mwOptions) {
+		options.propagators = p
+	}
+}
+
+// Middleware is a gin native middleware that traces requests using the
+// given OpenTelemetry TracerProvider. It mirrors the option surface of
+// ginhttp.Middleware so it can be adopted as a drop-in replacement by
+// services migrating from OpenTracing to OpenTelemetry.
+func Middleware(tp trace.TracerProvider, opts ...Option) gin.HandlerFunc {
+	options := mwOptions{
+		opNameFunc: func(r *http.Request) string {
+			return "HTTP " + r.Method + " " + r.URL.Path
+		},
+		spanObserver: func(span trace.Span, r *http.Request) {},
+		urlTagFunc: func(u *url.URL) string {
+			return u.RequestURI()
+		},
+		spanFilter: func(r *http.Request) bool { return true },
+		propagators: propagation.NewCompositeTextMapPropagator(
+			propagation.TraceContext{}, propagation.Baggage{}),
+	}
+	for _, opt := range opts {
+		opt(&options)
+	}
+
+	tracer := tp.Tracer(tracerName)
+
+	componentName := options.componentName
+	if componentName == "" {
+		componentName = defaultComponentName
+	}
+
+	return func(c *gin.Context) {
+		r := c.Request
+		if !options.spanFilter(r) {
+			c.Next()
+			return
+		}
+
+		ctx := options.propagators.Extract(r.Context(), propagation.HeaderCarrier(r.Header))
+
+		ctx, span := tracer.Start(ctx, options.opNameFunc(r), trace.WithSpanKind(trace.SpanKindServer),
+			trace.WithAttributes(
+				semconv.HTTPMethodKey.String(r.Method),
+				semconv.HTTPTargetKey.String(options.urlTagFunc(r.URL)),
+				semconv.NetHostNameKey.String(r.Host),
+				semconv.UserAgentOriginalKey.String(r.UserAgent()),
+				attribute.String("component", componentName),
+			))
+		defer span.End()
+
+		options.spanObserver(span, r)
+		c.Request = r.WithContext(ctx)
+
+		c.Next()
+
+		route := c.FullPath()
+		if route != "" {
+			span.SetAttributes(semconv.HTTPRouteKey.String(route))
+		}
+
+		status := c.Writer.Status()
+		span.SetAttributes(semconv.HTTPStatusCodeKey.Int(status))
+
+		// Per the OTel HTTP semantic conventions, server spans only get
+		// marked Error for 5xx; 4xx is a valid application response and
+		// is left Unset so SLO dashboards don't conflate it with failures.
+		if status >= 500 {
+			span.SetStatus(codes.Error, http.StatusText(status))
+		}
+		for _, err := range c.Errors {
+			span.RecordError(err.Err)
+		}
+	}
+}
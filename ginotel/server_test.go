@@ -0,0 +1,60 @@
+// +build go1.7
+
+package ginotel
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func init() {
+	gin.SetMode(gin.TestMode)
+}
+
+// TestMiddlewareDefaultPropagatorExtractsTraceContext proves Middleware's
+// default propagator actually decodes an incoming W3C traceparent header
+// without the host application having called otel.SetTextMapPropagator --
+// the drop-in, do-nothing-else use case this package advertises.
+func TestMiddlewareDefaultPropagatorExtractsTraceContext(t *testing.T) {
+	const (
+		wantTraceID = "80f198ee56343ba864fe8b2a57d3eff7"
+		wantSpanID  = "e457b5a2e4d86bd1"
+	)
+
+	var got trace.SpanContext
+	observer := SpanObserver(func(span trace.Span, r *http.Request) {
+		got = span.SpanContext()
+	})
+
+	router := gin.New()
+	router.Use(Middleware(trace.NewNoopTracerProvider(), observer))
+	router.GET("/ping", func(c *gin.Context) {})
+
+	req := httptest.NewRequest(http.MethodGet, "/ping", nil)
+	req.Header.Set("traceparent", "00-"+wantTraceID+"-"+wantSpanID+"-01")
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+
+	wantTID, err := trace.TraceIDFromHex(wantTraceID)
+	if err != nil {
+		t.Fatalf("TraceIDFromHex: %v", err)
+	}
+	if got.TraceID() != wantTID {
+		t.Errorf("extracted TraceID = %s, want %s", got.TraceID(), wantTID)
+	}
+
+	wantSID, err := trace.SpanIDFromHex(wantSpanID)
+	if err != nil {
+		t.Fatalf("SpanIDFromHex: %v", err)
+	}
+	if got.SpanID() != wantSID {
+		t.Errorf("extracted SpanID = %s, want %s", got.SpanID(), wantSID)
+	}
+	if !got.IsSampled() {
+		t.Errorf("extracted SpanContext is not marked sampled")
+	}
+}